@@ -15,19 +15,24 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"time"
 
+	"github.com/blang/semver"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pulumi/pulumi/sdk/v3/go/common/resource/plugin"
 	"github.com/pulumi/pulumi/sdk/v3/go/common/util/logging"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
 
 	"github.com/pulumi/pulumi/pkg/v3/backend/display"
 	"github.com/pulumi/pulumi/pkg/v3/engine"
@@ -40,6 +45,10 @@ func newInstallCmd() *cobra.Command {
 	var reinstall bool
 	var noPlugins, noDependencies bool
 	var useLanguageVersionTools bool
+	var parallel int
+	var frozenLockfile, updateLockfile bool
+	var pluginSourceDir, exportBundle string
+	var dryRun, verify bool
 
 	cmd := &cobra.Command{
 		Use:   "install",
@@ -54,6 +63,10 @@ func newInstallCmd() *cobra.Command {
 				Color: cmdutil.GetGlobalColorization(),
 			}
 
+			if frozenLockfile && updateLockfile {
+				return errors.New("--frozen-lockfile and --update-lockfile cannot be used together")
+			}
+
 			installPolicyPackDeps, err := shouldInstallPolicyPackDependencies()
 			if err != nil {
 				return err
@@ -107,7 +120,9 @@ func newInstallCmd() *cobra.Command {
 				return fmt.Errorf("load language plugin %s: %w", runtime.Name(), err)
 			}
 
-			if !noDependencies {
+			// --dry-run must not touch the filesystem or network beyond metadata lookups, so skip the
+			// real dependency install (which can run npm/pip/etc.) entirely when it's set.
+			if !noDependencies && !dryRun {
 				if err = lang.InstallDependencies(plugin.InstallDependenciesRequest{
 					Info:                    programInfo,
 					UseLanguageVersionTools: useLanguageVersionTools,
@@ -123,54 +138,42 @@ func newInstallCmd() *cobra.Command {
 					return err
 				}
 
-				// Now for each kind, name, version pair, download it from the release website, and install it.
-				for _, install := range installs {
-					// PluginSpec.String() just returns the name and version, we want the kind too.
-					label := fmt.Sprintf("%s plugin %s", install.Kind, install)
-
-					// If the plugin already exists, don't download it unless --reinstall was passed.
-					if !reinstall {
-						if install.Version != nil {
-							if workspace.HasPlugin(install) {
-								logging.V(1).Infof("%s skipping install (existing == match)", label)
-								continue
-							}
-						} else {
-							if has, _ := workspace.HasPluginGTE(install); has {
-								logging.V(1).Infof("%s skipping install (existing >= match)", label)
-								continue
-							}
-						}
-					}
+				lockPath := pluginLockfilePath(root)
+				lock, err := readPluginLockfile(lockPath)
+				if err != nil {
+					return err
+				}
 
-					pctx.Diag.Infoerrf(diag.Message("", "%s installing"), label)
+				sourceDir := resolvePluginSourceDir(pluginSourceDir)
 
-					// If we got here, actually try to do the download.
-					withProgress := func(stream io.ReadCloser, size int64) io.ReadCloser {
-						return workspace.ReadCloserProgressBar(stream, size, "Downloading plugin", displayOpts.Color)
-					}
-					retry := func(err error, attempt int, limit int, delay time.Duration) {
-						pctx.Diag.Warningf(
-							diag.Message("", "Error downloading plugin: %s\nWill retry in %v [%d/%d]"), err, delay, attempt, limit)
-					}
+				if dryRun {
+					return printInstallPlan(ctx, cmd.OutOrStdout(), installs, reinstall, lock, sourceDir)
+				}
 
-					r, err := workspace.DownloadToFile(install, withProgress, retry)
-					if err != nil {
-						return fmt.Errorf("%s downloading from %s: %w", label, install.PluginDownloadURL, err)
+				if exportBundle != "" {
+					return exportPluginBundle(ctx, pctx, installs, exportBundle, displayOpts)
+				}
+
+				if frozenLockfile && lock == nil {
+					return fmt.Errorf("--frozen-lockfile was set but %s does not exist; run "+
+						"`pulumi install --update-lockfile` first", pluginLockfileName)
+				}
+
+				resolved, err := installPlugins(
+					ctx, pctx, installs, reinstall, parallel, frozenLockfile, lock, sourceDir, updateLockfile, displayOpts)
+				if err != nil {
+					return err
+				}
+
+				if updateLockfile {
+					if err := writePluginLockfile(lockPath, &pluginLockfile{Plugins: resolved}); err != nil {
+						return err
 					}
-					defer func() {
-						err := os.Remove(r.Name())
-						if err != nil {
-							pctx.Diag.Warningf(
-								diag.Message("", "Error removing temporary file %s: %s"), r.Name(), err)
-						}
-					}()
-
-					payload := workspace.TarPlugin(r)
-
-					logging.V(1).Infof("%s installing tarball ...", label)
-					if err = install.InstallWithContext(ctx, payload, reinstall); err != nil {
-						return fmt.Errorf("installing %s: %w", label, err)
+				}
+
+				if verify {
+					if err := verifyInstalledPlugins(pctx, resolved); err != nil {
+						return err
 					}
 				}
 			}
@@ -187,10 +190,295 @@ func newInstallCmd() *cobra.Command {
 		"no-dependencies", false, "Skip installing dependencies")
 	cmd.PersistentFlags().BoolVar(&useLanguageVersionTools,
 		"use-language-version-tools", false, "Use language version tools to setup and install the language runtime")
+	cmd.PersistentFlags().IntVar(&parallel,
+		"parallel", runtime.NumCPU(), "Download and install this many plugins in parallel")
+	cmd.PersistentFlags().BoolVar(&frozenLockfile,
+		"frozen-lockfile", false, "Fail if installing would change the resolved plugin versions in pulumi.plugins.lock")
+	cmd.PersistentFlags().BoolVar(&updateLockfile,
+		"update-lockfile", false, "Write the resolved plugin versions to pulumi.plugins.lock")
+	cmd.PersistentFlags().StringVar(&pluginSourceDir,
+		"plugin-source-dir", "", "Install plugins from pre-staged tarballs in this directory instead of downloading "+
+			"them (defaults to $"+pluginSourceDirEnvVar+")")
+	cmd.PersistentFlags().StringVar(&exportBundle,
+		"export-bundle", "", "Download all required plugins into this directory, using the same naming "+
+			"convention as --plugin-source-dir, without installing them")
+	cmd.PersistentFlags().BoolVar(&dryRun,
+		"dry-run", false, "Print the planned install/skip/upgrade/reinstall actions without touching "+
+			"the filesystem or downloading any plugins")
+	cmd.PersistentFlags().BoolVar(&verify,
+		"verify", false, "After installing, start each resource plugin and confirm it responds over "+
+			"the plugin protocol")
 
 	return cmd
 }
 
+// installPlugins downloads and installs the given plugins, fanning the work out across a bounded
+// pool of workers. Up to parallel plugins are downloaded and installed concurrently; the final
+// error, if any, reports failures in the same order the plugins were requested regardless of
+// which worker finished first. It returns the lockfile entries resolved for each installed or
+// skipped plugin, suitable for writing out with --update-lockfile.
+//
+// Running InstallWithContext for several plugins at once is safe without any extra locking in
+// workspace: each PluginSpec installs into its own kind/name/version directory under the plugin
+// cache, and distinct installs in a single `pulumi install` never share that directory, so there's
+// no mutable state for concurrent workers to race on. The only shared resource is the terminal, so
+// progress reporting (not installation itself) is what's throttled back below whenever more than
+// one plugin can be in flight at once.
+func installPlugins(
+	ctx context.Context, pctx *plugin.Context, installs []workspace.PluginSpec,
+	reinstall bool, parallel int, frozenLockfile bool, lock *pluginLockfile, sourceDir string, needChecksum bool,
+	displayOpts display.Options,
+) ([]pluginLockEntry, error) {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	sem := semaphore.NewWeighted(int64(parallel))
+	group, groupCtx := errgroup.WithContext(ctx)
+	errs := make([]error, len(installs))
+	entries := make([]pluginLockEntry, len(installs))
+	attempted := make([]bool, len(installs))
+
+	// A single progress bar writing to the terminal is fine, but several workers drawing their own
+	// bars at once interleaves and garbles the output. --parallel defaults to NumCPU, so gate on
+	// whether more than one plugin can actually be in flight at once, not just the configured pool
+	// size: a single-plugin install should always get a progress bar.
+	showProgress := parallel <= 1 || len(installs) <= 1
+
+	var acquireErr error
+	for i, install := range installs {
+		i, install := i, install
+
+		if err := sem.Acquire(groupCtx, 1); err != nil {
+			acquireErr = err
+			break
+		}
+		attempted[i] = true
+		group.Go(func() error {
+			defer sem.Release(1)
+			entries[i], errs[i] = installPlugin(
+				groupCtx, pctx, install, reinstall, frozenLockfile, lock, sourceDir, needChecksum, showProgress, displayOpts)
+			return nil
+		})
+	}
+
+	// The closures above never return a non-nil error, by design: we want every plugin that
+	// started to run to completion so the error report below is comprehensive, rather than
+	// aborting the rest of the pool on the first failure. group.Wait() here only waits for the
+	// plugins that did start.
+	group.Wait()
+
+	if acquireErr != nil {
+		return nil, fmt.Errorf("installing plugins: %w", acquireErr)
+	}
+
+	var failures []string
+	for i, err := range errs {
+		if !attempted[i] {
+			continue
+		}
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) > 0 {
+		return nil, fmt.Errorf("installing plugins:\n%s", strings.Join(failures, "\n"))
+	}
+	return entries, nil
+}
+
+// installPlugin downloads and installs a single plugin, skipping the work entirely if a matching
+// version is already present and reinstall was not requested. It is safe to call concurrently for
+// different plugins.
+//
+// If lock has an entry for this plugin, that entry's version and download URL are used instead of
+// the resolved install, and the downloaded artifact's checksum is verified against it. If
+// frozenLockfile is set and no matching entry is found, installPlugin fails rather than resolving
+// a new version. If the project pins its own version and it disagrees with the locked one,
+// installPlugin fails under frozenLockfile rather than silently installing the stale locked
+// version. Otherwise, if install has no pinned version, installPlugin resolves and records
+// the concrete latest version so the lock entry it returns is always a real semver, never the
+// unresolved "latest" -- except when sourceDir is set, in which case that resolution is skipped
+// (see below).
+//
+// If sourceDir is non-empty, the plugin tarball is read from that directory instead of being
+// downloaded, and installPlugin fails loudly if it isn't there rather than falling back to the
+// network. In that case, an unpinned install.Version is never resolved against the network either:
+// the local lookup already tolerates "latest" in the expected file name, and --plugin-source-dir
+// exists for hosts that may have no network to resolve it with.
+//
+// needChecksum is set when the caller is about to write pulumi.plugins.lock (--update-lockfile).
+// If the plugin is already installed but we don't have a checksum for it yet, installPlugin
+// downloads and reinstalls it anyway so the lockfile doesn't end up with a blank sha256.
+//
+// showProgress controls whether the download is rendered as a progress bar; callers running more
+// than one installPlugin concurrently should pass false, since multiple bars writing to the same
+// terminal at once interleave into garbage.
+func installPlugin(
+	ctx context.Context, pctx *plugin.Context, install workspace.PluginSpec,
+	reinstall bool, frozenLockfile bool, lock *pluginLockfile, sourceDir string, needChecksum bool,
+	showProgress bool, displayOpts display.Options,
+) (pluginLockEntry, error) {
+	// PluginSpec.String() just returns the name and version, we want the kind too.
+	label := fmt.Sprintf("%s plugin %s", install.Kind, install)
+
+	entry, locked := lock.find(string(install.Kind), install.Name)
+	if locked {
+		version, err := semver.ParseTolerant(entry.Version)
+		if err != nil {
+			return pluginLockEntry{}, fmt.Errorf("%s: invalid version %q in %s: %w", label, entry.Version, pluginLockfileName, err)
+		}
+		// The project itself may pin an explicit version in Pulumi.yaml. If that disagrees with
+		// what's locked, the project was changed without running --update-lockfile: under
+		// frozenLockfile that's exactly the drift --frozen-lockfile exists to catch, so fail instead
+		// of silently installing the stale locked version.
+		if install.Version != nil && !install.Version.EQ(version) {
+			if frozenLockfile {
+				return pluginLockEntry{}, fmt.Errorf(
+					"%s: project requires version %s but %s has %s locked; run "+
+						"`pulumi install --update-lockfile` to update it",
+					label, install.Version, pluginLockfileName, version)
+			}
+			pctx.Diag.Warningf(diag.Message("", "%s: project requires version %s but %s has %s locked; run "+
+				"`pulumi install --update-lockfile` to update it"), label, install.Version, pluginLockfileName, version)
+		}
+		install.Version = &version
+		if entry.DownloadURL != "" {
+			install.PluginDownloadURL = entry.DownloadURL
+		}
+		label = fmt.Sprintf("%s plugin %s", install.Kind, install)
+	} else if frozenLockfile {
+		return pluginLockEntry{}, fmt.Errorf("%s: no entry in %s and --frozen-lockfile was set", label, pluginLockfileName)
+	}
+
+	// Plugins the project doesn't pin a version for (install.Version == nil, the common case)
+	// resolve to "latest" somewhere downstream of here. We need the concrete version up front, both
+	// to record a real semver in pulumi.plugins.lock instead of the literal string "latest" (which
+	// isn't valid semver and would break the next `pulumi install`) and so the rest of this function
+	// can check for an existing install by exact version. But when sourceDir is set, the local
+	// tarball lookup below already tolerates a nil version (falling back to the literal "latest" in
+	// the expected file name), so skip this resolution: it's a network call, and the whole point of
+	// --plugin-source-dir is installing on hosts that may not have one.
+	if install.Version == nil && sourceDir == "" {
+		latest, err := install.GetLatestVersion()
+		if err != nil {
+			return pluginLockEntry{}, fmt.Errorf("%s: resolving latest version: %w", label, err)
+		}
+		install.Version = latest
+		label = fmt.Sprintf("%s plugin %s", install.Kind, install)
+	}
+
+	version := "latest"
+	if install.Version != nil {
+		version = install.Version.String()
+	}
+	resultEntry := pluginLockEntry{
+		Kind:        string(install.Kind),
+		Name:        install.Name,
+		Version:     version,
+		DownloadURL: install.PluginDownloadURL,
+		SHA256:      entry.SHA256,
+	}
+
+	// If the plugin already exists, don't download it unless --reinstall was passed. The exception
+	// is when the caller is about to write pulumi.plugins.lock and we don't have a checksum for
+	// this plugin yet: skipping there would silently write an entry with a blank sha256, so fall
+	// through and download/reinstall it once to compute one.
+	forceForChecksum := false
+	if !reinstall {
+		exists := false
+		if install.Version != nil {
+			exists = workspace.HasPlugin(install)
+		} else {
+			exists, _ = workspace.HasPluginGTE(install)
+		}
+		if exists {
+			if !needChecksum || resultEntry.SHA256 != "" {
+				logging.V(1).Infof("%s skipping install (existing matches)", label)
+				return resultEntry, nil
+			}
+			logging.V(1).Infof(
+				"%s already installed but missing a checksum in %s; reinstalling to record one",
+				label, pluginLockfileName)
+			forceForChecksum = true
+		}
+	}
+
+	pctx.Diag.Infoerrf(diag.Message("", "%s installing"), label)
+
+	// If a plugin source dir was given, install from the pre-staged tarball instead of the network.
+	if sourceDir != "" {
+		path, err := findPluginInSourceDir(sourceDir, install)
+		if err != nil {
+			return pluginLockEntry{}, err
+		}
+		sha, err := sha256File(path)
+		if err != nil {
+			return pluginLockEntry{}, fmt.Errorf("%s: %w", label, err)
+		}
+		if locked && entry.SHA256 != "" && sha != entry.SHA256 {
+			return pluginLockEntry{}, fmt.Errorf(
+				"%s: checksum %s does not match %s in %s", label, sha, entry.SHA256, pluginLockfileName)
+		}
+		resultEntry.SHA256 = sha
+
+		f, err := os.Open(path)
+		if err != nil {
+			return pluginLockEntry{}, fmt.Errorf("%s: opening %s: %w", label, path, err)
+		}
+		defer f.Close()
+
+		logging.V(1).Infof("%s installing tarball from %s ...", label, path)
+		if err = install.InstallWithContext(ctx, workspace.TarPlugin(f), reinstall || forceForChecksum); err != nil {
+			return pluginLockEntry{}, fmt.Errorf("installing %s: %w", label, err)
+		}
+		return resultEntry, nil
+	}
+
+	// If we got here, actually try to do the download.
+	withProgress := func(stream io.ReadCloser, size int64) io.ReadCloser {
+		if !showProgress {
+			logging.V(1).Infof("%s downloading ...", label)
+			return stream
+		}
+		return workspace.ReadCloserProgressBar(stream, size, fmt.Sprintf("Downloading %s", label), displayOpts.Color)
+	}
+	retry := func(err error, attempt int, limit int, delay time.Duration) {
+		pctx.Diag.Warningf(
+			diag.Message("", "Error downloading %s: %s\nWill retry in %v [%d/%d]"), label, err, delay, attempt, limit)
+	}
+
+	r, err := workspace.DownloadToFile(install, withProgress, retry)
+	if err != nil {
+		return pluginLockEntry{}, fmt.Errorf("%s downloading from %s: %w", label, install.PluginDownloadURL, err)
+	}
+	defer func() {
+		err := os.Remove(r.Name())
+		if err != nil {
+			pctx.Diag.Warningf(
+				diag.Message("", "Error removing temporary file %s: %s"), r.Name(), err)
+		}
+	}()
+
+	sha, err := sha256File(r.Name())
+	if err != nil {
+		return pluginLockEntry{}, fmt.Errorf("%s: %w", label, err)
+	}
+	if locked && entry.SHA256 != "" && sha != entry.SHA256 {
+		return pluginLockEntry{}, fmt.Errorf(
+			"%s: checksum %s does not match %s in %s", label, sha, entry.SHA256, pluginLockfileName)
+	}
+	resultEntry.SHA256 = sha
+
+	payload := workspace.TarPlugin(r)
+
+	logging.V(1).Infof("%s installing tarball ...", label)
+	if err = install.InstallWithContext(ctx, payload, reinstall || forceForChecksum); err != nil {
+		return pluginLockEntry{}, fmt.Errorf("installing %s: %w", label, err)
+	}
+	return resultEntry, nil
+}
+
 func shouldInstallPolicyPackDependencies() (bool, error) {
 	cwd, err := os.Getwd()
 	if err != nil {
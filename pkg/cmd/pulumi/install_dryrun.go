@@ -0,0 +1,163 @@
+// Copyright 2016-2023, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/blang/semver"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/workspace"
+)
+
+// pluginDownloadSizeTimeout bounds the metadata-only HEAD request --dry-run makes per plugin, so
+// an unreachable download URL (the situation hosts without network access are likely to be in)
+// can't hang the command indefinitely.
+const pluginDownloadSizeTimeout = 5 * time.Second
+
+// resolvePlannedInstall substitutes the locked version and download URL for install, mirroring the
+// substitution installPlugin performs before downloading, so the dry-run plan reports the same
+// version, URL, and action a real `pulumi install` would use when pulumi.plugins.lock is present.
+// It returns the resolved spec and whether a lock entry was found.
+func resolvePlannedInstall(install workspace.PluginSpec, lock *pluginLockfile) (workspace.PluginSpec, bool, error) {
+	entry, locked := lock.find(string(install.Kind), install.Name)
+	if !locked {
+		return install, false, nil
+	}
+	version, err := semver.ParseTolerant(entry.Version)
+	if err != nil {
+		return install, false, fmt.Errorf("invalid version %q in %s: %w", entry.Version, pluginLockfileName, err)
+	}
+	install.Version = &version
+	if entry.DownloadURL != "" {
+		install.PluginDownloadURL = entry.DownloadURL
+	}
+	return install, true, nil
+}
+
+// planPluginAction reports what `pulumi install` would do for install without touching the
+// filesystem or network: skip it, install it fresh, upgrade an older version already present, or
+// reinstall it because --reinstall was passed.
+func planPluginAction(install workspace.PluginSpec, reinstall bool) string {
+	if install.Version != nil {
+		if workspace.HasPlugin(install) {
+			if reinstall {
+				return "reinstall"
+			}
+			return "skip"
+		}
+		// An older version of the same plugin is already present, so installing this one upgrades it.
+		plugins, _ := workspace.GetPlugins()
+		for _, p := range plugins {
+			if p.Kind == install.Kind && p.Name == install.Name && p.Version != nil && p.Version.LT(*install.Version) {
+				return "upgrade"
+			}
+		}
+		return "install"
+	}
+	if has, _ := workspace.HasPluginGTE(install); has {
+		if reinstall {
+			return "reinstall"
+		}
+		return "skip"
+	}
+	return "install"
+}
+
+// pluginDownloadSize does a HEAD request against the plugin's download URL to report its size
+// without actually downloading it. It returns "?" if the size can't be determined, including when
+// the request doesn't complete within pluginDownloadSizeTimeout.
+func pluginDownloadSize(ctx context.Context, install workspace.PluginSpec) string {
+	if install.PluginDownloadURL == "" {
+		return "?"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pluginDownloadSizeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, install.PluginDownloadURL, nil)
+	if err != nil {
+		return "?"
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "?"
+	}
+	defer resp.Body.Close()
+	if resp.ContentLength <= 0 {
+		return "?"
+	}
+	return fmt.Sprintf("%.1f MiB", float64(resp.ContentLength)/(1024*1024))
+}
+
+// pluginSourceFileSize stats the pre-staged tarball for install inside dir and reports its size.
+// It mirrors pluginDownloadSize but never touches the network, since --plugin-source-dir installs
+// target air-gapped environments where a live HEAD request is both pointless and slow. It returns
+// "?" if the tarball can't be found or stat'd.
+func pluginSourceFileSize(dir string, install workspace.PluginSpec) string {
+	path, err := findPluginInSourceDir(dir, install)
+	if err != nil {
+		return "?"
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "?"
+	}
+	return fmt.Sprintf("%.1f MiB", float64(info.Size())/(1024*1024))
+}
+
+// printInstallPlan prints a table of the action `pulumi install --dry-run` would take for each
+// required plugin, without installing anything.
+//
+// If lock has an entry for a plugin, that entry's version and download URL are substituted in
+// before planning, so the printed plan matches what installPlugin would actually do once
+// pulumi.plugins.lock is consulted. If sourceDir is non-empty, sizes are read from the local
+// tarball instead of the network, matching how a real --plugin-source-dir install behaves.
+func printInstallPlan(
+	ctx context.Context, out io.Writer, installs []workspace.PluginSpec, reinstall bool,
+	lock *pluginLockfile, sourceDir string,
+) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tVERSION\tACTION\tSIZE\tURL")
+	for _, install := range installs {
+		resolved, _, err := resolvePlannedInstall(install, lock)
+		if err != nil {
+			return fmt.Errorf("%s plugin %s: %w", install.Kind, install, err)
+		}
+
+		version := "latest"
+		if resolved.Version != nil {
+			version = resolved.Version.String()
+		}
+		action := planPluginAction(resolved, reinstall)
+		size := "-"
+		if action != "skip" {
+			if sourceDir != "" {
+				size = pluginSourceFileSize(sourceDir, resolved)
+			} else {
+				size = pluginDownloadSize(ctx, resolved)
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			resolved.Kind, resolved.Name, version, action, size, resolved.PluginDownloadURL)
+	}
+	return w.Flush()
+}
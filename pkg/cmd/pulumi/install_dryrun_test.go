@@ -0,0 +1,99 @@
+// Copyright 2016-2023, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blang/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/workspace"
+)
+
+func TestResolvePlannedInstall_noLock(t *testing.T) {
+	t.Parallel()
+
+	install := workspace.PluginSpec{Kind: "resource", Name: "aws"}
+	resolved, locked, err := resolvePlannedInstall(install, nil)
+	require.NoError(t, err)
+	assert.False(t, locked)
+	assert.Equal(t, install, resolved)
+}
+
+func TestResolvePlannedInstall_substitutesLockedVersionAndURL(t *testing.T) {
+	t.Parallel()
+
+	lock := &pluginLockfile{
+		Plugins: []pluginLockEntry{
+			{Kind: "resource", Name: "aws", Version: "5.4.0", DownloadURL: "https://example.com/aws"},
+		},
+	}
+	install := workspace.PluginSpec{Kind: "resource", Name: "aws"}
+
+	resolved, locked, err := resolvePlannedInstall(install, lock)
+	require.NoError(t, err)
+	assert.True(t, locked)
+	require.NotNil(t, resolved.Version)
+	assert.Equal(t, "5.4.0", resolved.Version.String())
+	assert.Equal(t, "https://example.com/aws", resolved.PluginDownloadURL)
+}
+
+func TestResolvePlannedInstall_invalidLockedVersion(t *testing.T) {
+	t.Parallel()
+
+	lock := &pluginLockfile{
+		Plugins: []pluginLockEntry{
+			{Kind: "resource", Name: "aws", Version: "not-a-version"},
+		},
+	}
+	install := workspace.PluginSpec{Kind: "resource", Name: "aws"}
+
+	_, _, err := resolvePlannedInstall(install, lock)
+	assert.Error(t, err)
+}
+
+func TestPlanPluginAction_notInstalled(t *testing.T) {
+	// workspace.HasPlugin/HasPluginGTE read from $PULUMI_HOME/plugins; point that at an empty temp
+	// dir so both report nothing installed, regardless of the plugin cache's internal layout.
+	t.Setenv("PULUMI_HOME", t.TempDir())
+
+	version := semver.MustParse("5.4.0")
+	pinned := workspace.PluginSpec{Kind: "resource", Name: "aws", Version: &version}
+	assert.Equal(t, "install", planPluginAction(pinned, false))
+	assert.Equal(t, "install", planPluginAction(pinned, true))
+
+	unpinned := workspace.PluginSpec{Kind: "resource", Name: "aws"}
+	assert.Equal(t, "install", planPluginAction(unpinned, false))
+	assert.Equal(t, "install", planPluginAction(unpinned, true))
+}
+
+func TestPluginSourceFileSize(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	version := semver.MustParse("5.4.0")
+	install := workspace.PluginSpec{Kind: "resource", Name: "aws", Version: &version}
+
+	assert.Equal(t, "?", pluginSourceFileSize(dir, install), "missing tarball should report unknown size, not error")
+
+	path := filepath.Join(dir, pluginBundleFileName(install))
+	require.NoError(t, os.WriteFile(path, make([]byte, 2*1024*1024), 0o600))
+
+	assert.Equal(t, "2.0 MiB", pluginSourceFileSize(dir, install))
+}
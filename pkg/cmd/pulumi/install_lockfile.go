@@ -0,0 +1,114 @@
+// Copyright 2016-2023, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// pluginLockfileName is the name of the lockfile `pulumi install` reads and writes next to
+// Pulumi.yaml to make plugin installs reproducible across machines.
+const pluginLockfileName = "pulumi.plugins.lock"
+
+// pluginLockEntry records the exact resolved artifact for a single plugin: the version that was
+// chosen, the URL it was downloaded from, and a checksum to verify future downloads against.
+type pluginLockEntry struct {
+	Kind        string `json:"kind"`
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	DownloadURL string `json:"downloadURL,omitempty"`
+	SHA256      string `json:"sha256,omitempty"`
+}
+
+// pluginLockfile is the on-disk format of pulumi.plugins.lock.
+type pluginLockfile struct {
+	Plugins []pluginLockEntry `json:"plugins"`
+}
+
+func pluginLockfilePath(root string) string {
+	return filepath.Join(root, pluginLockfileName)
+}
+
+// readPluginLockfile reads and parses the lockfile at path, returning a nil lockfile (and no
+// error) if no lockfile exists yet.
+func readPluginLockfile(path string) (*pluginLockfile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading plugin lockfile: %w", err)
+	}
+	var lock pluginLockfile
+	if err := json.Unmarshal(b, &lock); err != nil {
+		return nil, fmt.Errorf("parsing plugin lockfile %s: %w", path, err)
+	}
+	return &lock, nil
+}
+
+// writePluginLockfile writes the lockfile to path, sorting entries for a stable diff.
+func writePluginLockfile(path string, lock *pluginLockfile) error {
+	sort.Slice(lock.Plugins, func(i, j int) bool {
+		if lock.Plugins[i].Kind != lock.Plugins[j].Kind {
+			return lock.Plugins[i].Kind < lock.Plugins[j].Kind
+		}
+		return lock.Plugins[i].Name < lock.Plugins[j].Name
+	})
+	b, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling plugin lockfile: %w", err)
+	}
+	b = append(b, '\n')
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		return fmt.Errorf("writing plugin lockfile %s: %w", path, err)
+	}
+	return nil
+}
+
+// find returns the lock entry for the given plugin kind/name, if any. It is safe to call on a nil
+// lockfile, which happens when no pulumi.plugins.lock is present.
+func (l *pluginLockfile) find(kind, name string) (pluginLockEntry, bool) {
+	if l == nil {
+		return pluginLockEntry{}, false
+	}
+	for _, e := range l.Plugins {
+		if e.Kind == kind && e.Name == name {
+			return e, true
+		}
+	}
+	return pluginLockEntry{}, false
+}
+
+// sha256File hashes the file at path and returns its checksum as a lowercase hex string.
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hashing %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
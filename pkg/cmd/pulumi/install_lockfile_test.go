@@ -0,0 +1,116 @@
+// Copyright 2016-2023, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadPluginLockfile_missing(t *testing.T) {
+	t.Parallel()
+
+	lock, err := readPluginLockfile(filepath.Join(t.TempDir(), pluginLockfileName))
+	require.NoError(t, err)
+	assert.Nil(t, lock)
+}
+
+func TestWriteReadPluginLockfile_roundTrip(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), pluginLockfileName)
+	want := &pluginLockfile{
+		Plugins: []pluginLockEntry{
+			{Kind: "resource", Name: "aws", Version: "5.4.0", SHA256: "abc"},
+			{Kind: "resource", Name: "gcp", Version: "6.0.0", DownloadURL: "https://example.com/gcp"},
+		},
+	}
+
+	require.NoError(t, writePluginLockfile(path, want))
+
+	got, err := readPluginLockfile(path)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, want.Plugins, got.Plugins)
+}
+
+func TestWritePluginLockfile_sortsEntries(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), pluginLockfileName)
+	lock := &pluginLockfile{
+		Plugins: []pluginLockEntry{
+			{Kind: "resource", Name: "gcp", Version: "1.0.0"},
+			{Kind: "language", Name: "nodejs", Version: "1.0.0"},
+			{Kind: "resource", Name: "aws", Version: "1.0.0"},
+		},
+	}
+	require.NoError(t, writePluginLockfile(path, lock))
+
+	got, err := readPluginLockfile(path)
+	require.NoError(t, err)
+	require.Len(t, got.Plugins, 3)
+	assert.Equal(t, "language", got.Plugins[0].Kind)
+	assert.Equal(t, "resource", got.Plugins[1].Kind)
+	assert.Equal(t, "aws", got.Plugins[1].Name)
+	assert.Equal(t, "resource", got.Plugins[2].Kind)
+	assert.Equal(t, "gcp", got.Plugins[2].Name)
+}
+
+func TestReadPluginLockfile_invalidJSON(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), pluginLockfileName)
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0o600))
+
+	_, err := readPluginLockfile(path)
+	assert.Error(t, err)
+}
+
+func TestPluginLockfile_find(t *testing.T) {
+	t.Parallel()
+
+	var nilLock *pluginLockfile
+	_, ok := nilLock.find("resource", "aws")
+	assert.False(t, ok, "find on a nil lockfile should report no match, not panic")
+
+	lock := &pluginLockfile{
+		Plugins: []pluginLockEntry{
+			{Kind: "resource", Name: "aws", Version: "5.4.0"},
+		},
+	}
+	entry, ok := lock.find("resource", "aws")
+	require.True(t, ok)
+	assert.Equal(t, "5.4.0", entry.Version)
+
+	_, ok = lock.find("resource", "gcp")
+	assert.False(t, ok)
+}
+
+func TestSha256File(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "plugin.tar.gz")
+	require.NoError(t, os.WriteFile(path, []byte("hello"), 0o600))
+
+	sha, err := sha256File(path)
+	require.NoError(t, err)
+	// sha256("hello")
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", sha)
+}
@@ -0,0 +1,132 @@
+// Copyright 2016-2023, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pulumi/pulumi/pkg/v3/backend/display"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource/plugin"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/logging"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/workspace"
+)
+
+// pluginSourceDirEnvVar lets operators point `pulumi install` at a local cache of pre-staged
+// plugin tarballs instead of the network, for air-gapped environments.
+const pluginSourceDirEnvVar = "PULUMI_PLUGIN_SOURCE_DIR"
+
+// resolvePluginSourceDir returns the effective plugin source directory: the --plugin-source-dir
+// flag if set, otherwise the PULUMI_PLUGIN_SOURCE_DIR environment variable, otherwise "".
+func resolvePluginSourceDir(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	return os.Getenv(pluginSourceDirEnvVar)
+}
+
+// pluginBundleFileName returns the file name a plugin tarball is expected to have inside a plugin
+// source directory or export bundle, e.g. "pulumi-resource-aws-v5.4.0.tar.gz".
+func pluginBundleFileName(install workspace.PluginSpec) string {
+	version := "latest"
+	if install.Version != nil {
+		version = install.Version.String()
+	}
+	return fmt.Sprintf("pulumi-%s-%s-v%s.tar.gz", install.Kind, install.Name, version)
+}
+
+// findPluginInSourceDir locates the pre-staged tarball for install inside dir, failing loudly if
+// it is missing rather than silently falling back to the network.
+func findPluginInSourceDir(dir string, install workspace.PluginSpec) (string, error) {
+	path := filepath.Join(dir, pluginBundleFileName(install))
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf(
+				"%s plugin %s not found in plugin source dir %s (expected %s)",
+				install.Kind, install, dir, path)
+		}
+		return "", fmt.Errorf("checking for %s in plugin source dir %s: %w", install, dir, err)
+	}
+	return path, nil
+}
+
+// exportPluginBundle downloads every required plugin into dir using the bundle naming convention,
+// without installing any of them, so the directory can be transferred into an air-gapped
+// environment and later consumed via --plugin-source-dir.
+func exportPluginBundle(
+	ctx context.Context, pctx *plugin.Context, installs []workspace.PluginSpec, dir string, displayOpts display.Options,
+) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("creating export bundle dir %s: %w", dir, err)
+	}
+
+	for _, install := range installs {
+		label := fmt.Sprintf("%s plugin %s", install.Kind, install)
+		pctx.Diag.Infoerrf(diag.Message("", "%s downloading for export"), label)
+
+		withProgress := func(stream io.ReadCloser, size int64) io.ReadCloser {
+			return workspace.ReadCloserProgressBar(stream, size, fmt.Sprintf("Downloading %s", label), displayOpts.Color)
+		}
+		retry := func(err error, attempt int, limit int, delay time.Duration) {
+			pctx.Diag.Warningf(
+				diag.Message("", "Error downloading %s: %s\nWill retry in %v [%d/%d]"), label, err, delay, attempt, limit)
+		}
+
+		r, err := workspace.DownloadToFile(install, withProgress, retry)
+		if err != nil {
+			return fmt.Errorf("%s downloading from %s: %w", label, install.PluginDownloadURL, err)
+		}
+
+		dest := filepath.Join(dir, pluginBundleFileName(install))
+		copyErr := copyFile(r.Name(), dest)
+
+		// Remove the temp file as soon as it's been copied into the bundle dir, rather than
+		// waiting for the whole export to finish, so several large plugins don't pile up on disk
+		// at once on the constrained hosts this mode targets.
+		if err := os.Remove(r.Name()); err != nil {
+			pctx.Diag.Warningf(diag.Message("", "Error removing temporary file %s: %s"), r.Name(), err)
+		}
+
+		if copyErr != nil {
+			return fmt.Errorf("%s: writing %s: %w", label, dest, copyErr)
+		}
+		logging.V(1).Infof("%s exported to %s", label, dest)
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,66 @@
+// Copyright 2016-2023, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blang/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/workspace"
+)
+
+func TestResolvePluginSourceDir(t *testing.T) {
+	t.Setenv(pluginSourceDirEnvVar, "/from/env")
+
+	assert.Equal(t, "/from/flag", resolvePluginSourceDir("/from/flag"))
+	assert.Equal(t, "/from/env", resolvePluginSourceDir(""))
+
+	t.Setenv(pluginSourceDirEnvVar, "")
+	assert.Equal(t, "", resolvePluginSourceDir(""))
+}
+
+func TestPluginBundleFileName(t *testing.T) {
+	t.Parallel()
+
+	version := semver.MustParse("5.4.0")
+	pinned := workspace.PluginSpec{Kind: "resource", Name: "aws", Version: &version}
+	assert.Equal(t, "pulumi-resource-aws-v5.4.0.tar.gz", pluginBundleFileName(pinned))
+
+	unpinned := workspace.PluginSpec{Kind: "resource", Name: "aws"}
+	assert.Equal(t, "pulumi-resource-aws-vlatest.tar.gz", pluginBundleFileName(unpinned))
+}
+
+func TestFindPluginInSourceDir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	version := semver.MustParse("5.4.0")
+	install := workspace.PluginSpec{Kind: "resource", Name: "aws", Version: &version}
+
+	_, err := findPluginInSourceDir(dir, install)
+	require.Error(t, err, "missing tarball should be a loud error, not a silent network fallback")
+
+	want := filepath.Join(dir, pluginBundleFileName(install))
+	require.NoError(t, os.WriteFile(want, []byte("tarball"), 0o600))
+
+	got, err := findPluginInSourceDir(dir, install)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
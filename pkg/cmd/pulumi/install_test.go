@@ -0,0 +1,107 @@
+// Copyright 2016-2023, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pulumi/pulumi/pkg/v3/backend/display"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource/plugin"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/cmdutil"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/workspace"
+)
+
+// testPluginContext returns a plugin.Context suitable for exercising installPlugin/installPlugins
+// in tests. installPlugin only ever uses pctx.Diag for logging, never pctx.Host, so a nil host is
+// fine here.
+func testPluginContext(t *testing.T) *plugin.Context {
+	t.Helper()
+	pctx, err := plugin.NewContext(cmdutil.Diag(), cmdutil.Diag(), nil, nil, t.TempDir(), nil, false, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = pctx.Close()
+	})
+	return pctx
+}
+
+// stageFakeBundle writes a placeholder tarball for install into dir, named the same way
+// --plugin-source-dir/--export-bundle expect, so findPluginInSourceDir can locate it.
+func stageFakeBundle(t *testing.T, dir string, install workspace.PluginSpec) {
+	t.Helper()
+	path := filepath.Join(dir, pluginBundleFileName(install))
+	require.NoError(t, os.WriteFile(path, []byte("not a real plugin tarball"), 0o600))
+}
+
+func TestInstallPlugins_errorsStayIndexOrdered(t *testing.T) {
+	t.Setenv("PULUMI_HOME", t.TempDir())
+
+	pctx := testPluginContext(t)
+	sourceDir := t.TempDir()
+
+	installs := []workspace.PluginSpec{
+		{Kind: "resource", Name: "plugin-a"},
+		{Kind: "resource", Name: "plugin-b"},
+		{Kind: "resource", Name: "plugin-c"},
+	}
+	// Only the first and last plugins have a staged tarball; the middle one is missing, so its
+	// error must still be reported between the other two, in request order, regardless of which
+	// worker happens to finish first.
+	stageFakeBundle(t, sourceDir, installs[0])
+	stageFakeBundle(t, sourceDir, installs[2])
+
+	_, err := installPlugins(
+		context.Background(), pctx, installs, false /* reinstall */, 3, /* parallel */
+		false /* frozenLockfile */, nil /* lock */, sourceDir, false /* needChecksum */, display.Options{})
+	require.Error(t, err)
+
+	ia := strings.Index(err.Error(), "plugin-a")
+	ib := strings.Index(err.Error(), "plugin-b")
+	ic := strings.Index(err.Error(), "plugin-c")
+	require.True(t, ia >= 0 && ib >= 0 && ic >= 0, "expected all three plugins mentioned in: %s", err)
+	assert.True(t, ia < ib && ib < ic, "failures must be reported in request order, not completion order: %s", err)
+}
+
+func TestInstallPlugin_sourceDirNeverResolvesUnpinnedVersionOverNetwork(t *testing.T) {
+	t.Setenv("PULUMI_HOME", t.TempDir())
+
+	pctx := testPluginContext(t)
+	sourceDir := t.TempDir()
+
+	// install.Version is nil (the project doesn't pin it) and PluginDownloadURL points at an
+	// address that would hang or fail if ever dialed. If installPlugin tried to resolve "latest"
+	// over the network before consulting sourceDir, this would surface as a "resolving latest
+	// version" error (or a hang); with the fix it must go straight to the local tarball instead.
+	install := workspace.PluginSpec{
+		Kind:              "resource",
+		Name:              "offline-plugin",
+		PluginDownloadURL: "http://192.0.2.1:1/unreachable", // TEST-NET-1, guaranteed unroutable
+	}
+	stageFakeBundle(t, sourceDir, install)
+
+	_, err := installPlugin(
+		context.Background(), pctx, install, false /* reinstall */, false, /* frozenLockfile */
+		nil /* lock */, sourceDir, false /* needChecksum */, false /* showProgress */, display.Options{})
+	if err != nil {
+		assert.NotContains(t, err.Error(), "resolving latest version",
+			"a source-dir install must never attempt to resolve the version over the network")
+	}
+}
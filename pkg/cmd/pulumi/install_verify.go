@@ -0,0 +1,81 @@
+// Copyright 2016-2023, Pulumi Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource/plugin"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/logging"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/workspace"
+)
+
+// verifyInstalledPlugins starts each installed resource plugin over the plugin gRPC protocol and
+// confirms it responds to GetPluginInfo, surfacing broken installs immediately rather than at
+// `pulumi up` time. Non-resource plugins (language, analyzer, converter, tool) aren't started this
+// way and are skipped.
+//
+// entries must be the lockfile entries installPlugins actually resolved and installed, not the
+// project's unresolved required-plugin specs: when pulumi.plugins.lock pins a version for a plugin
+// whose spec had no version constraint, only entries carries the version that was really
+// installed, so verification starts the same build rather than whatever "latest matching" resolves
+// to afterward.
+func verifyInstalledPlugins(pctx *plugin.Context, entries []pluginLockEntry) error {
+	var failures []string
+	for _, entry := range entries {
+		if entry.Kind != string(workspace.ResourcePlugin) {
+			continue
+		}
+		label := fmt.Sprintf("%s plugin %s@%s", entry.Kind, entry.Name, entry.Version)
+
+		var version *semver.Version
+		if entry.Version != "" && entry.Version != "latest" {
+			v, err := semver.ParseTolerant(entry.Version)
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s: invalid version %q: %s", label, entry.Version, err))
+				continue
+			}
+			version = &v
+		}
+
+		prov, err := pctx.Host.Provider(tokens.Package(entry.Name), version)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: starting: %s", label, err))
+			continue
+		}
+
+		info, err := prov.GetPluginInfo()
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: GetPluginInfo: %s", label, err))
+		} else {
+			schemaVersion := "unknown"
+			if info.Version != nil {
+				schemaVersion = info.Version.String()
+			}
+			logging.V(1).Infof("%s verified (schema version %s)", label, schemaVersion)
+		}
+
+		if err := prov.Close(); err != nil {
+			logging.V(1).Infof("%s: closing: %s", label, err)
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("verifying plugins:\n%s", strings.Join(failures, "\n"))
+	}
+	return nil
+}